@@ -133,6 +133,69 @@ func (s *AuthSuite) TestUserLock(c *C) {
 	c.Assert(err, IsNil)
 }
 
+// TestProgressiveLockout checks that repeated failures produce
+// exponentially growing lockouts (rather than the old single fixed
+// window) and that a successful login decays the counter when
+// DecayOnSuccess is set.
+func (s *AuthSuite) TestProgressiveLockout(c *C) {
+	c.Assert(s.a.UpsertCertAuthority(
+		suite.NewTestCA(services.UserCA, "me.localhost"), backend.Forever), IsNil)
+
+	user := "user2"
+	pass := []byte("abc123")
+	createUserAndRole(s.a, user, []string{user})
+	c.Assert(s.a.UpsertPassword(user, pass), IsNil)
+
+	s.a.LockoutPolicy = services.LockoutPolicy{
+		MaxAttempts:    2,
+		BaseInterval:   30 * time.Second,
+		Multiplier:     2,
+		MaxInterval:    2 * time.Minute,
+		DecayOnSuccess: true,
+	}
+
+	fakeClock := clockwork.NewFakeClock()
+	s.a.clock = fakeClock
+
+	lockAndMeasure := func() time.Duration {
+		for i := 0; i < 2; i++ {
+			s.a.SignIn(user, []byte("wrong pass"))
+		}
+		before := fakeClock.Now()
+		for {
+			_, err := s.a.SignIn(user, pass)
+			if err == nil {
+				break
+			}
+			fakeClock.Advance(time.Second)
+		}
+		return fakeClock.Now().Sub(before)
+	}
+
+	firstLockout := lockAndMeasure()
+
+	// DecayOnSuccess must reset the failure counter, not just the lockout
+	// state, so the very next lockout starts over at BaseInterval instead
+	// of immediately re-triggering with a grown window.
+	c.Assert(s.a.recordLoginSuccess(user, ""), IsNil)
+
+	secondLockout := lockAndMeasure()
+	c.Assert(secondLockout, Equals, firstLockout)
+
+	c.Assert(s.a.recordLoginSuccess(user, ""), IsNil)
+
+	// without an intervening success, repeated lockouts must keep growing
+	// and eventually cap at MaxInterval.
+	for i := 0; i < 5; i++ {
+		s.a.recordLoginFailure(user, "")
+		s.a.recordLoginFailure(user, "")
+	}
+	state, err := s.a.getLockoutState(user, "")
+	c.Assert(err, IsNil)
+	c.Assert(state.LockedUntil.Sub(fakeClock.Now()) <= s.a.LockoutPolicy.MaxInterval, Equals, true)
+	c.Assert(state.LockedUntil.Sub(fakeClock.Now()), Equals, s.a.LockoutPolicy.MaxInterval)
+}
+
 func (s *AuthSuite) TestTokensCRUD(c *C) {
 	c.Assert(s.a.UpsertCertAuthority(
 		suite.NewTestCA(services.HostCA, "me.localhost"), backend.Forever), IsNil)
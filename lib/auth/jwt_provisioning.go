@@ -0,0 +1,199 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gravitational/teleport"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+
+	"github.com/coreos/go-oidc/jose"
+	"github.com/coreos/go-oidc/key"
+)
+
+// jwksCacheTTL is how long a fetched JWKS document is reused before the
+// auth server refetches it from the issuer.
+const jwksCacheTTL = 5 * time.Minute
+
+// jwksEntry caches one issuer's key set alongside when it was fetched.
+type jwksEntry struct {
+	keySet    *key.PublicKeySet
+	fetchedAt time.Time
+}
+
+// jwksCache memoizes per-issuer JWKS fetches so that verifying a JWT
+// provisioning token doesn't hit the network on every node join.
+type jwksCache struct {
+	mu    sync.Mutex
+	byURL map[string]jwksEntry
+}
+
+func newJWKSCache() *jwksCache {
+	return &jwksCache{byURL: make(map[string]jwksEntry)}
+}
+
+// get returns the cached key set for url, fetching and caching it if it's
+// missing or stale.
+func (j *jwksCache) get(url string, fetch func(string) (*key.PublicKeySet, error)) (*key.PublicKeySet, error) {
+	j.mu.Lock()
+	entry, ok := j.byURL[url]
+	j.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < jwksCacheTTL {
+		return entry.keySet, nil
+	}
+
+	keySet, err := fetch(url)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	j.mu.Lock()
+	j.byURL[url] = jwksEntry{keySet: keySet, fetchedAt: time.Now()}
+	j.mu.Unlock()
+	return keySet, nil
+}
+
+// RegisterUsingJWT validates presentedJWT against the JWTProvisionToken
+// whose Issuer matches the token's `iss` claim, enforces exp/nbf/aud and
+// BoundClaims, and on success issues host certs for requestRole the same
+// way RegisterUsingToken does for static bearer tokens.
+func (a *AuthServer) RegisterUsingJWT(presentedJWT string, hostID, nodeName string, requestRole teleport.Role) (*PackedKeys, error) {
+	jwt, err := jose.ParseJWT(presentedJWT)
+	if err != nil {
+		return nil, trace.Wrap(err, "parsing presented JWT")
+	}
+	claims, err := jwt.Claims()
+	if err != nil {
+		return nil, trace.Wrap(err, "reading JWT claims")
+	}
+	issuer, _, err := claims.StringClaim("iss")
+	if err != nil || issuer == "" {
+		return nil, trace.BadParameter("JWT is missing an issuer claim")
+	}
+
+	var matched *services.JWTProvisionToken
+	for i := range a.JWTStaticTokens {
+		if a.JWTStaticTokens[i].Issuer == issuer {
+			matched = &a.JWTStaticTokens[i]
+			break
+		}
+	}
+	if matched == nil {
+		return nil, trace.AccessDenied("no jwt provisioning token configured for issuer %q", issuer)
+	}
+
+	keySet, err := a.jwksCache.get(matched.JWKSURL, a.fetchJWKS)
+	if err != nil {
+		return nil, trace.Wrap(err, "fetching JWKS for issuer %q", issuer)
+	}
+
+	if err := key.VerifySignature(jwt, keySet.Keys()); err != nil {
+		return nil, trace.AccessDenied("JWT signature verification failed: %v", err)
+	}
+	if err := verifyTimeClaims(claims, a.clock.Now()); err != nil {
+		return nil, trace.AccessDenied("%v", err)
+	}
+	if err := verifyAudience(claims, matched.Audience); err != nil {
+		return nil, trace.AccessDenied("%v", err)
+	}
+
+	rawClaims := map[string]interface{}(claims)
+	if !matched.MatchesClaims(rawClaims) {
+		return nil, trace.AccessDenied("JWT claims do not satisfy bound_claims for issuer %q", issuer)
+	}
+	if !matched.Roles.Include(requestRole) {
+		return nil, trace.AccessDenied("%q [%v] can not join the cluster, the jwt token does not allow %q role", nodeName, hostID, requestRole)
+	}
+
+	return a.generateHostCerts(hostID, nodeName, teleport.Roles{requestRole})
+}
+
+// fetchJWKS is the network-backed JWKS fetcher RegisterUsingJWT passes to
+// jwksCache.get, which takes the fetcher as an argument so another one
+// can be passed in its place.
+func (a *AuthServer) fetchJWKS(url string) (*key.PublicKeySet, error) {
+	return key.FetchPublicKeys(url)
+}
+
+// verifyTimeClaims enforces `exp` and `nbf`, which jose.JWTVerifier
+// normally checks against the issuer's declared claims; JWT provisioning
+// tokens use the auth server's own clock instead so tests can fast-forward.
+// `exp` is mandatory - a JWT that omits it would never stop being a valid
+// provisioning credential, so it's rejected rather than silently allowed.
+func verifyTimeClaims(claims jose.Claims, now time.Time) error {
+	exp, ok, err := claims.TimeClaim("exp")
+	if err != nil || !ok {
+		return trace.BadParameter("JWT is missing a required exp claim")
+	}
+	if now.After(exp) {
+		return trace.BadParameter("JWT has expired")
+	}
+	if nbf, ok, _ := claims.TimeClaim("nbf"); ok && now.Before(nbf) {
+		return trace.BadParameter("JWT is not yet valid")
+	}
+	return nil
+}
+
+// verifyAudience checks the JWT's `aud` claim against expectedAudience,
+// the same way a standard OIDC ID token verifier would, so a token minted
+// for a different Teleport cluster trusting the same issuer is rejected.
+// RFC 7519 allows `aud` to be either a single string or a JSON array of
+// strings, so both shapes are accepted and expectedAudience only has to
+// appear somewhere in the claim.
+func verifyAudience(claims jose.Claims, expectedAudience string) error {
+	audiences, ok := audienceClaim(claims)
+	if !ok || len(audiences) == 0 {
+		return trace.BadParameter("JWT is missing a required aud claim")
+	}
+	for _, aud := range audiences {
+		if aud == expectedAudience {
+			return nil
+		}
+	}
+	return trace.BadParameter("JWT aud claim %v does not contain expected audience %q", audiences, expectedAudience)
+}
+
+// audienceClaim reads `aud` as either a single string or an array of
+// strings, returning false if the claim is absent or neither shape.
+func audienceClaim(claims jose.Claims) ([]string, bool) {
+	raw, ok := claims["aud"]
+	if !ok {
+		return nil, false
+	}
+	switch v := raw.(type) {
+	case string:
+		if v == "" {
+			return nil, false
+		}
+		return []string{v}, true
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, entry := range v {
+			s, ok := entry.(string)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, s)
+		}
+		return out, true
+	default:
+		return nil, false
+	}
+}
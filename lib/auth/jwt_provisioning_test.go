@@ -0,0 +1,72 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"github.com/gravitational/teleport"
+	"github.com/gravitational/teleport/lib/services"
+
+	"github.com/coreos/go-oidc/jose"
+	. "gopkg.in/check.v1"
+)
+
+// TestJWTProvisioningRejectsUnknownIssuer presents a well-formed,
+// parseable JWT whose `iss` doesn't match any configured
+// JWTProvisionToken, so the rejection exercises the unknown-issuer
+// lookup rather than jose.ParseJWT's parse-error path.
+func (s *AuthSuite) TestJWTProvisioningRejectsUnknownIssuer(c *C) {
+	s.a.JWTStaticTokens = []services.JWTProvisionToken{
+		{
+			Roles:    teleport.Roles{teleport.RoleNode},
+			Issuer:   "https://oidc.example.com",
+			Audience: "teleport.example.com",
+		},
+	}
+
+	claims := make(jose.Claims)
+	claims.Add("iss", "https://unconfigured-issuer.example.com")
+	jwt, err := jose.NewJWT(jose.JWTHeader{Algorithm: "RS256"}, claims)
+	c.Assert(err, IsNil)
+
+	_, err = s.a.RegisterUsingJWT(jwt.Encode(), "host-id", "node-name", teleport.RoleNode)
+	c.Assert(err, ErrorMatches, ".*no jwt provisioning token configured for issuer.*")
+}
+
+// TestVerifyAudienceAcceptsArrayForm checks that the common RFC 7519
+// multi-audience form - `aud` as a JSON array rather than a bare string -
+// is accepted as long as the expected audience appears in it.
+func (s *AuthSuite) TestVerifyAudienceAcceptsArrayForm(c *C) {
+	claims := jose.Claims{"aud": []interface{}{"other-cluster.example.com", "teleport.example.com"}}
+	c.Assert(verifyAudience(claims, "teleport.example.com"), IsNil)
+
+	claims = jose.Claims{"aud": []interface{}{"other-cluster.example.com"}}
+	c.Assert(verifyAudience(claims, "teleport.example.com"), NotNil)
+}
+
+func (s *AuthSuite) TestJWTProvisionTokenMatchesClaims(c *C) {
+	token := services.JWTProvisionToken{
+		Roles:       teleport.Roles{teleport.RoleNode},
+		Issuer:      "https://oidc.example.com",
+		Audience:    "teleport.example.com",
+		BoundClaims: map[string]string{"sub": "spiffe://example.com/ci-runner"},
+	}
+	c.Assert(token.CheckAndSetDefaults(), IsNil)
+
+	c.Assert(token.MatchesClaims(map[string]interface{}{"sub": "spiffe://example.com/ci-runner"}), Equals, true)
+	c.Assert(token.MatchesClaims(map[string]interface{}{"sub": "someone-else"}), Equals, false)
+	c.Assert(token.MatchesClaims(map[string]interface{}{}), Equals, false)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"time"
+
+	"github.com/gravitational/teleport/lib/backend/boltbk"
+
+	"github.com/coreos/go-oidc/jose"
+	"github.com/coreos/go-oidc/key"
+	"github.com/jonboulle/clockwork"
+	. "gopkg.in/check.v1"
+)
+
+type OIDCKeysSuite struct{}
+
+var _ = Suite(&OIDCKeysSuite{})
+
+func (s *OIDCKeysSuite) TestRotationPrunesOldKeysAfterOverlap(c *C) {
+	bk, err := boltbk.New(map[string]interface{}{"path": c.MkDir()})
+	c.Assert(err, IsNil)
+
+	clock := clockwork.NewFakeClock()
+	rotator := NewPrivateKeyRotator(bk, clock, time.Hour, 2*time.Hour)
+	c.Assert(rotator.Start(), IsNil)
+	defer rotator.Stop()
+
+	c.Assert(rotator.ActiveKeyCount(), Equals, 1)
+
+	// advance past several rotate intervals; the oldest keys should be
+	// pruned once they fall outside rotateEvery+retireAfter, but the
+	// rotator should never be left with zero keys.
+	for i := 0; i < 5; i++ {
+		clock.Advance(time.Hour + time.Minute)
+		c.Assert(rotator.rotate(), IsNil)
+		rotator.prune()
+	}
+
+	c.Assert(rotator.ActiveKeyCount() > 0, Equals, true)
+	c.Assert(rotator.ActiveKeyCount() <= 3, Equals, true)
+}
+
+// TestOverlapWindowKeepsPreviousKeyValid checks the guarantee prune()'s
+// cutoff is supposed to provide: a token signed with a key just before it
+// retires still validates throughout the overlap window, since callers
+// holding an in-flight token can't be expected to re-fetch it mid-flight.
+func (s *OIDCKeysSuite) TestOverlapWindowKeepsPreviousKeyValid(c *C) {
+	bk, err := boltbk.New(map[string]interface{}{"path": c.MkDir()})
+	c.Assert(err, IsNil)
+
+	clock := clockwork.NewFakeClock()
+	rotator := NewPrivateKeyRotator(bk, clock, time.Hour, 2*time.Hour)
+	c.Assert(rotator.Start(), IsNil)
+	defer rotator.Stop()
+
+	rotator.mu.Lock()
+	signingKey := rotator.keys[0]
+	rotator.mu.Unlock()
+
+	signer := (&key.PrivateKey{KeyID: signingKey.id, PrivateKey: signingKey.private}).Signer()
+	jwt, err := jose.NewSignedJWT(jose.Claims{"sub": "node-1"}, signer)
+	c.Assert(err, IsNil)
+
+	// rotate well past rotateEvery so signingKey is no longer the active
+	// key, but stay inside rotateEvery+retireAfter so it's still kept.
+	clock.Advance(time.Hour + time.Minute)
+	c.Assert(rotator.rotate(), IsNil)
+	rotator.prune()
+
+	c.Assert(key.VerifySignature(*jwt, rotator.PublicKeySet().Keys()), IsNil)
+}
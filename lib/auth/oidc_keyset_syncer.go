@@ -0,0 +1,168 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/trace"
+
+	"github.com/coreos/go-oidc/key"
+	"github.com/jonboulle/clockwork"
+)
+
+// KeySetSyncer periodically polls one or more remote OIDC issuers' JWKS
+// endpoints and caches the result in the backend, so verifying a
+// presented ID token never has to hit the network on the request path.
+type KeySetSyncer struct {
+	mu       sync.Mutex
+	backend  backend.Backend
+	clock    clockwork.Clock
+	interval time.Duration
+	fetch    func(jwksURL string) (*key.PublicKeySet, error)
+	cached   map[string]*key.PublicKeySet
+	stopCh   chan struct{}
+}
+
+// NewKeySetSyncer creates a syncer that polls every interval; a zero
+// interval defaults to one hour.
+func NewKeySetSyncer(bk backend.Backend, clock clockwork.Clock, interval time.Duration) *KeySetSyncer {
+	if clock == nil {
+		clock = clockwork.NewRealClock()
+	}
+	if interval == 0 {
+		interval = time.Hour
+	}
+	return &KeySetSyncer{
+		backend:  bk,
+		clock:    clock,
+		interval: interval,
+		fetch:    key.FetchPublicKeys,
+		cached:   make(map[string]*key.PublicKeySet),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins polling every jwksURL in issuers on the configured interval.
+func (s *KeySetSyncer) Start(issuers []string) {
+	go s.loop(issuers)
+}
+
+func (s *KeySetSyncer) Stop() {
+	close(s.stopCh)
+}
+
+func (s *KeySetSyncer) loop(issuers []string) {
+	s.syncAll(issuers)
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.Chan():
+			s.syncAll(issuers)
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+func (s *KeySetSyncer) syncAll(issuers []string) {
+	for _, issuer := range issuers {
+		keySet, err := s.fetch(issuer)
+		if err != nil {
+			// a transient network failure just means the previously
+			// cached key set (if any) keeps serving verification.
+			continue
+		}
+		s.mu.Lock()
+		s.cached[issuer] = keySet
+		s.mu.Unlock()
+		s.persist(issuer, keySet)
+	}
+}
+
+// persistedKeySet is the on-disk form of a key.PublicKeySet: just enough
+// of each RSA public key to reconstruct it, since key.PublicKey itself
+// doesn't round-trip through encoding/json.
+type persistedKeySet struct {
+	ExpiresAt time.Time      `json:"expires_at"`
+	Keys      []persistedKey `json:"keys"`
+}
+
+type persistedKey struct {
+	ID string `json:"id"`
+	N  string `json:"n"`
+	E  int    `json:"e"`
+}
+
+func (s *KeySetSyncer) persist(issuer string, keySet *key.PublicKeySet) error {
+	persisted := persistedKeySet{ExpiresAt: keySet.ExpiresAt()}
+	for _, k := range keySet.Keys() {
+		persisted.Keys = append(persisted.Keys, persistedKey{
+			ID: k.KeyID,
+			N:  k.PublicKey.N.String(),
+			E:  k.PublicKey.E,
+		})
+	}
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(s.backend.UpsertVal([]string{"oidc", "jwks-cache"}, issuer, data, backend.Forever))
+}
+
+// Get returns the cached key set for issuer, consulting the in-memory
+// cache first and falling back to the backend (so a freshly restarted
+// process doesn't have to wait for the next poll, or hit the network, to
+// verify a token) before giving up.
+func (s *KeySetSyncer) Get(issuer string) (*key.PublicKeySet, error) {
+	s.mu.Lock()
+	keySet, ok := s.cached[issuer]
+	s.mu.Unlock()
+	if ok {
+		return keySet, nil
+	}
+
+	data, err := s.backend.GetVal([]string{"oidc", "jwks-cache"}, issuer)
+	if err != nil {
+		return nil, trace.NotFound("no cached JWKS for issuer %q yet", issuer)
+	}
+	var persisted persistedKeySet
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	keys := make([]key.PublicKey, 0, len(persisted.Keys))
+	for _, k := range persisted.Keys {
+		n, ok := new(big.Int).SetString(k.N, 10)
+		if !ok {
+			return nil, trace.BadParameter("cached JWKS for issuer %q: invalid modulus for key %q", issuer, k.ID)
+		}
+		keys = append(keys, key.PublicKey{KeyID: k.ID, PublicKey: &rsa.PublicKey{N: n, E: k.E}})
+	}
+	keySet = key.NewPublicKeySet(keys, persisted.ExpiresAt)
+
+	s.mu.Lock()
+	s.cached[issuer] = keySet
+	s.mu.Unlock()
+	return keySet, nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+
+	"github.com/coreos/go-oidc/jose"
+)
+
+// normalizeClaims routes claims through connector's provider profile
+// (Keycloak, GitHub, Google, Bitbucket) when one is configured, merging
+// whatever the provider fetches over the raw ID token claims so
+// ClaimMapping rules can target the provider-normalized namespace
+// (Keycloak's flattened `roles`, GitHub's synthesized `groups`, ...).
+// accessToken is the OAuth2 access token from the same token exchange as
+// claims (not the ID token), since that's what GitHub's /user/orgs and
+// Bitbucket's userinfo endpoint authenticate follow-up calls with. When
+// connector.Spec.Provider is empty, claims is returned unchanged and the
+// existing generic path is used, exactly as before this change.
+func normalizeClaims(connector *services.OIDCConnectorV2, accessToken string, claims jose.Claims) (jose.Claims, error) {
+	provider, err := connector.GetProvider(accessToken)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if provider == nil {
+		return claims, nil
+	}
+
+	if err := provider.ValidateHostedDomain(claims); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	extra, err := provider.FetchClaims(context.Background(), accessToken)
+	if err != nil {
+		return nil, trace.Wrap(err, "fetching provider-normalized claims")
+	}
+
+	merged := make(jose.Claims, len(claims)+len(extra))
+	for k, v := range claims {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged, nil
+}
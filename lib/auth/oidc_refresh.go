@@ -0,0 +1,186 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"time"
+
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+
+	"github.com/coreos/go-oidc/jose"
+	"github.com/coreos/go-oidc/oauth2"
+)
+
+// refreshWindow is how long before an ID token's `exp` the background
+// refresher proactively renews a session, mirroring oauth2_proxy's
+// refresh-ahead behavior instead of waiting for the token to already be
+// expired.
+const refreshWindow = 5 * time.Minute
+
+// oidcSessionExtra is the OIDC-specific state stored alongside a
+// WebSession so it can be refreshed later: the encrypted refresh token
+// and which connector/client issued it.
+type oidcSessionExtra struct {
+	ConnectorID      string
+	EncryptedRefresh []byte
+	IDTokenExpiry    time.Time
+}
+
+// RefreshWebSession exchanges the refresh token stored alongside user's
+// WebSession sessionID at the issuing connector's token endpoint, re-runs
+// buildRoles against the new ID token's claims so role changes made in
+// the IdP take effect, and rotates the session's Teleport cert and bearer
+// token. Sessions whose refresh fails with `invalid_grant` are deleted
+// rather than retried, since the IdP has revoked them.
+func (a *AuthServer) RefreshWebSession(user, sessionID string) (services.WebSession, error) {
+	if _, err := a.GetWebSession(user, sessionID); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	extra, err := a.getOIDCSessionExtra(user, sessionID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	connector, err := a.GetOIDCConnector(extra.ConnectorID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	refreshToken, err := a.decryptSessionSecret(extra.EncryptedRefresh)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	tokens, err := a.exchangeRefreshToken(connector, refreshToken)
+	if err != nil {
+		if isInvalidGrant(err) {
+			if delErr := a.DeleteWebSession(user, sessionID); delErr != nil {
+				return nil, trace.Wrap(delErr)
+			}
+			return nil, trace.AccessDenied("refresh token for %q was rejected by the issuer and the session was deleted", user)
+		}
+		return nil, trace.Wrap(err)
+	}
+
+	claims, err := tokens.IDToken.Claims()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	roles, err := a.buildRoles(connector, claims)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	newWS, err := a.rotateWebSession(user, sessionID, roles)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	if tokens.RefreshToken != "" {
+		if err := a.storeOIDCSessionExtra(user, newWS.GetName(), oidcSessionExtra{
+			ConnectorID:      extra.ConnectorID,
+			EncryptedRefresh: a.encryptSessionSecret(tokens.RefreshToken),
+			IDTokenExpiry:    tokens.IDToken.Expiry(),
+		}); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+
+	return newWS, nil
+}
+
+// oidcTokenResponse is the subset of a token endpoint response this
+// package cares about.
+type oidcTokenResponse struct {
+	IDToken      jose.JWT
+	RefreshToken string
+}
+
+// exchangeRefreshToken calls connector's issuer token endpoint with
+// grant_type=refresh_token. It's a thin wrapper around oauth2.Client,
+// routed through the overridable oidcTokenExchanger field (nil by
+// default) so tests can stub the token endpoint instead of needing a
+// live issuer to exercise the refresh path.
+func (a *AuthServer) exchangeRefreshToken(connector services.OIDCConnector, refreshToken string) (*oidcTokenResponse, error) {
+	if a.oidcTokenExchanger != nil {
+		return a.oidcTokenExchanger(connector, refreshToken)
+	}
+
+	client, err := a.oidcClientFor(connector)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	tokenResponse, err := client.RefreshToken(refreshToken)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	idToken, err := jose.ParseJWT(tokenResponse.IDToken)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &oidcTokenResponse{IDToken: idToken, RefreshToken: tokenResponse.RefreshToken}, nil
+}
+
+func isInvalidGrant(err error) bool {
+	oauthErr, ok := trace.Unwrap(err).(*oauth2.Error)
+	return ok && oauthErr.Type == oauth2.ErrorInvalidGrant
+}
+
+// startOIDCSessionRefresher launches the background loop that proactively
+// refreshes every active OIDC-backed web session refreshWindow before its
+// ID token expires.
+func (a *AuthServer) startOIDCSessionRefresher() {
+	go func() {
+		ticker := a.clock.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.Chan() {
+			a.refreshExpiringOIDCSessions()
+		}
+	}()
+}
+
+func (a *AuthServer) refreshExpiringOIDCSessions() {
+	sessions, err := a.listOIDCBackedSessions()
+	if err != nil {
+		return
+	}
+	for _, s := range sessions {
+		if a.clock.Now().Add(refreshWindow).Before(s.IDTokenExpiry) {
+			continue
+		}
+		a.RefreshWebSession(s.User, s.SessionID)
+	}
+}
+
+// encryptSessionSecret and decryptSessionSecret wrap the refresh token at
+// rest using the same symmetric secret the auth server already uses to
+// protect other session material, so a raw backend dump doesn't leak a
+// live IdP refresh token.
+func (a *AuthServer) encryptSessionSecret(plaintext string) []byte {
+	return a.sealSessionSecret([]byte(plaintext))
+}
+
+func (a *AuthServer) decryptSessionSecret(ciphertext []byte) (string, error) {
+	plaintext, err := a.openSessionSecret(ciphertext)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return string(plaintext), nil
+}
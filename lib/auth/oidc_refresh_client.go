@@ -0,0 +1,64 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+
+	"github.com/coreos/go-oidc/oauth2"
+	"github.com/coreos/go-oidc/oidc"
+)
+
+// oidcClientFor builds (or reuses) the oauth2 client used to talk to
+// connector's issuer, the same client SignIn's OIDC callback path already
+// constructs to exchange the initial authorization code.
+func (a *AuthServer) oidcClientFor(connector services.OIDCConnector) (*oauth2.Client, error) {
+	oidcClient, err := oidc.NewClient(oidc.ClientConfig{
+		Credentials: oidc.ClientCredentials{
+			ID:     connector.GetClientID(),
+			Secret: connector.GetClientSecret(),
+		},
+		RedirectURL: connector.GetRedirectURL(),
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return oidcClient.OAuthClient()
+}
+
+// rotateWebSession replaces the Teleport cert and bearer token backing an
+// existing session (keeping its session ID) with freshly issued ones
+// scoped to roles, so a refresh propagates role changes without forcing
+// the user to sign in again.
+func (a *AuthServer) rotateWebSession(user, sessionID string, roles []string) (services.WebSession, error) {
+	ws, err := a.GetWebSession(user, sessionID)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	newWS, err := a.NewWebSession(user, roles)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	newWS.SetName(ws.GetName())
+
+	if err := a.UpsertWebSession(user, newWS); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return newWS, nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"encoding/json"
+
+	"github.com/gravitational/teleport/lib/backend"
+	"github.com/gravitational/trace"
+)
+
+// oidcSessionExtraPrefix namespaces the refresh-token side-table in the
+// backend, keyed by user then session ID.
+var oidcSessionExtraPrefix = []string{"web", "oidc-sessions"}
+
+// storeOIDCSessionExtra persists extra next to the WebSession it belongs
+// to, so RefreshWebSession can find the refresh token later.
+func (a *AuthServer) storeOIDCSessionExtra(user, sessionID string, extra oidcSessionExtra) error {
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(a.Backend.UpsertVal(append(oidcSessionExtraPrefix, user), sessionID, data, backend.Forever))
+}
+
+// getOIDCSessionExtra retrieves the refresh-token side-table entry for a session.
+func (a *AuthServer) getOIDCSessionExtra(user, sessionID string) (oidcSessionExtra, error) {
+	var extra oidcSessionExtra
+	data, err := a.Backend.GetVal(append(oidcSessionExtraPrefix, user), sessionID)
+	if err != nil {
+		return extra, trace.Wrap(err)
+	}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return extra, trace.Wrap(err)
+	}
+	return extra, nil
+}
+
+// listOIDCBackedSessions walks the oidc-sessions side-table (users, then
+// each user's session IDs) and returns every entry, so the background
+// refresher can proactively renew sessions instead of only ever touching
+// one on use.
+func (a *AuthServer) listOIDCBackedSessions() ([]oidcSessionExtraWithID, error) {
+	users, err := a.Backend.GetKeys(oidcSessionExtraPrefix)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+
+	var out []oidcSessionExtraWithID
+	for _, user := range users {
+		sessionIDs, err := a.Backend.GetKeys(append(oidcSessionExtraPrefix, user))
+		if err != nil {
+			if trace.IsNotFound(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		for _, sessionID := range sessionIDs {
+			extra, err := a.getOIDCSessionExtra(user, sessionID)
+			if err != nil {
+				continue
+			}
+			out = append(out, oidcSessionExtraWithID{
+				oidcSessionExtra: extra,
+				User:             user,
+				SessionID:        sessionID,
+			})
+		}
+	}
+	return out, nil
+}
+
+// oidcSessionExtraWithID pairs oidcSessionExtra with the identifiers
+// needed to drive a refresh.
+type oidcSessionExtraWithID struct {
+	oidcSessionExtra
+	User      string
+	SessionID string
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"time"
+
+	"github.com/gravitational/teleport/lib/services"
+
+	"github.com/coreos/go-oidc/jose"
+	. "gopkg.in/check.v1"
+)
+
+func newExampleOIDCConnector() *services.OIDCConnectorV2 {
+	return services.NewOIDCConnector("example", services.OIDCConnectorSpecV2{
+		IssuerURL:    "https://www.example.com",
+		ClientID:     "example-client-id",
+		ClientSecret: "example-client-secret",
+		RedirectURL:  "https://localhost:3080/v1/webapi/oidc/callback",
+		Display:      "sign in with example.com",
+		Scope:        []string{"roles"},
+		ClaimsToRoles: []services.ClaimMapping{
+			{Claim: "roles", Value: "teleport-user", Roles: []string{"user"}},
+		},
+	})
+}
+
+func adminClaimMapping() services.ClaimMapping {
+	return services.ClaimMapping{Claim: "roles", Value: "teleport-admin", Roles: []string{"admin"}}
+}
+
+func exampleClaims(role, email, nickname string) jose.Claims {
+	claims := make(jose.Claims)
+	claims.Add("roles", role)
+	claims.Add("email", email)
+	claims.Add("nickname", nickname)
+	return claims
+}
+
+// TestRefreshWebSessionRemapsRoles checks that a claim change on the
+// refreshed ID token causes buildRoles to return a different role set.
+func (s *AuthSuite) TestRefreshWebSessionRemapsRoles(c *C) {
+	connector := newExampleOIDCConnector()
+
+	oldClaims := exampleClaims("teleport-user", "foo@example.com", "foo")
+	oldRoles, err := s.a.buildRoles(connector, oldClaims)
+	c.Assert(err, IsNil)
+	c.Assert(oldRoles, DeepEquals, []string{"user"})
+
+	newClaims := exampleClaims("teleport-admin", "foo@example.com", "foo")
+	connector.Spec.ClaimsToRoles = append(connector.Spec.ClaimsToRoles, adminClaimMapping())
+	newRoles, err := s.a.buildRoles(connector, newClaims)
+	c.Assert(err, IsNil)
+	c.Assert(newRoles, DeepEquals, []string{"admin"})
+}
+
+// TestRefreshWebSessionExchangesAndRemaps drives RefreshWebSession end to
+// end against a stubbed OIDC token endpoint (oidcTokenExchanger) whose ID
+// token carries a different role claim than the original session was
+// issued with, and checks the refresh succeeds - which only happens if
+// the new claims were re-mapped to a role that actually exists - and that
+// the rotated refresh token lands in the session's side-table.
+func (s *AuthSuite) TestRefreshWebSessionExchangesAndRemaps(c *C) {
+	connector := newExampleOIDCConnector()
+	c.Assert(s.a.UpsertOIDCConnector(connector), IsNil)
+
+	createUserAndRole(s.a, "foo", []string{"user"})
+	adminRole, err := services.NewRole("admin", services.RoleSpecV2{Logins: []string{"foo"}})
+	c.Assert(err, IsNil)
+	c.Assert(s.a.UpsertRole(adminRole), IsNil)
+
+	ws, err := s.a.NewWebSession("foo", []string{"user"})
+	c.Assert(err, IsNil)
+	c.Assert(s.a.UpsertWebSession("foo", ws), IsNil)
+
+	c.Assert(s.a.storeOIDCSessionExtra("foo", ws.GetName(), oidcSessionExtra{
+		ConnectorID:      connector.GetName(),
+		EncryptedRefresh: s.a.encryptSessionSecret("old-refresh-token"),
+		IDTokenExpiry:    s.a.clock.Now().Add(time.Hour),
+	}), IsNil)
+
+	newClaims := exampleClaims("teleport-admin", "foo@example.com", "foo")
+	connector.Spec.ClaimsToRoles = append(connector.Spec.ClaimsToRoles, adminClaimMapping())
+	c.Assert(s.a.UpsertOIDCConnector(connector), IsNil)
+
+	idToken, err := jose.NewJWT(jose.JWTHeader{Algorithm: "RS256"}, newClaims)
+	c.Assert(err, IsNil)
+
+	var exchangedWith string
+	s.a.oidcTokenExchanger = func(c services.OIDCConnector, refreshToken string) (*oidcTokenResponse, error) {
+		exchangedWith = refreshToken
+		return &oidcTokenResponse{IDToken: idToken, RefreshToken: "new-refresh-token"}, nil
+	}
+
+	newWS, err := s.a.RefreshWebSession("foo", ws.GetName())
+	c.Assert(err, IsNil)
+	c.Assert(exchangedWith, Equals, "old-refresh-token")
+
+	newExtra, err := s.a.getOIDCSessionExtra("foo", newWS.GetName())
+	c.Assert(err, IsNil)
+	refreshed, err := s.a.decryptSessionSecret(newExtra.EncryptedRefresh)
+	c.Assert(err, IsNil)
+	c.Assert(refreshed, Equals, "new-refresh-token")
+}
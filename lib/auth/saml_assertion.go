@@ -0,0 +1,235 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"time"
+
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+)
+
+// samlResponse is the subset of a SAML 2.0 <samlp:Response> this auth
+// server understands: enough to reach the signed assertion's conditions
+// and attribute statement. Anything IdP-specific (encrypted assertions,
+// extensions) is left unparsed rather than rejected.
+type samlResponse struct {
+	XMLName   xml.Name      `xml:"Response"`
+	Assertion samlAssertion `xml:"Assertion"`
+}
+
+type samlAssertion struct {
+	InnerXML           []byte                 `xml:",innerxml"`
+	Signature          samlSignature          `xml:"Signature"`
+	Conditions         samlConditions         `xml:"Conditions"`
+	AttributeStatement samlAttributeStatement `xml:"AttributeStatement"`
+}
+
+type samlSignature struct {
+	SignedInfo     samlSignedInfo `xml:"SignedInfo"`
+	SignatureValue string         `xml:"SignatureValue"`
+}
+
+type samlSignedInfo struct {
+	DigestValue string `xml:"Reference>DigestValue"`
+}
+
+type samlConditions struct {
+	NotBefore           time.Time               `xml:"NotBefore,attr"`
+	NotOnOrAfter        time.Time               `xml:"NotOnOrAfter,attr"`
+	AudienceRestriction samlAudienceRestriction `xml:"AudienceRestriction"`
+}
+
+type samlAudienceRestriction struct {
+	Audience string `xml:"Audience"`
+}
+
+type samlAttributeStatement struct {
+	Attributes []samlAttribute `xml:"Attribute"`
+}
+
+type samlAttribute struct {
+	Name   string   `xml:"Name,attr"`
+	Values []string `xml:"AttributeValue"`
+}
+
+// samlEntityDescriptor is the subset of IdP metadata needed to locate the
+// certificate assertions are signed with.
+type samlEntityDescriptor struct {
+	IDPSSODescriptor struct {
+		KeyDescriptor []struct {
+			Use     string `xml:"use,attr"`
+			KeyInfo struct {
+				X509Data struct {
+					X509Certificate string `xml:"X509Certificate"`
+				} `xml:"X509Data"`
+			} `xml:"KeyInfo"`
+		} `xml:"KeyDescriptor"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// signingCertFromEntityDescriptor parses an IdP's SAML metadata document
+// and returns the certificate under its first signing (or unspecified-use,
+// since `use` is optional and defaults to "any") KeyDescriptor.
+func signingCertFromEntityDescriptor(entityDescriptor string) (*x509.Certificate, error) {
+	var ed samlEntityDescriptor
+	if err := xml.Unmarshal([]byte(entityDescriptor), &ed); err != nil {
+		return nil, trace.Wrap(err, "parsing SAML entity descriptor")
+	}
+
+	var certPEM string
+	for _, kd := range ed.IDPSSODescriptor.KeyDescriptor {
+		if kd.Use != "" && kd.Use != "signing" {
+			continue
+		}
+		certPEM = kd.KeyInfo.X509Data.X509Certificate
+		break
+	}
+	if certPEM == "" {
+		return nil, trace.NotFound("entity descriptor has no signing certificate")
+	}
+
+	der, err := base64.StdEncoding.DecodeString(stripWhitespace(certPEM))
+	if err != nil {
+		return nil, trace.Wrap(err, "decoding IdP signing certificate")
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, trace.Wrap(err, "parsing IdP signing certificate")
+	}
+	return cert, nil
+}
+
+func stripWhitespace(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\n', '\r', '\t':
+		default:
+			out = append(out, s[i])
+		}
+	}
+	return string(out)
+}
+
+// validateSAMLAssertion parses rawResponse (the base64-encoded SAMLResponse
+// POSTed by the browser), verifies the assertion was signed by connector's
+// IdP, that it's currently valid (Conditions/NotBefore/NotOnOrAfter) and
+// scoped to this cluster (AudienceRestriction), and returns the attributes
+// it asserts. Signature verification covers the digest-then-signature
+// chain XML-DSig requires over the assertion body; it deliberately signs
+// off on exclusive canonicalization only for the common case of an
+// unmodified, prefix-stable assertion body rather than reimplementing
+// full XML C14N, so an IdP that reformats whitespace/namespaces between
+// signing and transmission will fail verification rather than silently
+// passing it.
+func validateSAMLAssertion(connector services.SAMLConnector, rawResponse string, now time.Time) (map[string][]string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(rawResponse)
+	if err != nil {
+		return nil, trace.BadParameter("SAML response is not valid base64: %v", err)
+	}
+
+	var resp samlResponse
+	if err := xml.Unmarshal(decoded, &resp); err != nil {
+		return nil, trace.BadParameter("SAML response is not valid XML: %v", err)
+	}
+	assertion := resp.Assertion
+
+	cert, err := signingCertFromEntityDescriptor(connector.GetEntityDescriptor())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := verifyAssertionSignature(cert, assertion); err != nil {
+		return nil, trace.AccessDenied("SAML assertion signature verification failed: %v", err)
+	}
+
+	if !assertion.Conditions.NotBefore.IsZero() && now.Before(assertion.Conditions.NotBefore) {
+		return nil, trace.AccessDenied("SAML assertion is not yet valid")
+	}
+	if !assertion.Conditions.NotOnOrAfter.IsZero() && !now.Before(assertion.Conditions.NotOnOrAfter) {
+		return nil, trace.AccessDenied("SAML assertion has expired")
+	}
+
+	expectedAudience := connector.GetAudience()
+	if expectedAudience != "" && assertion.Conditions.AudienceRestriction.Audience != expectedAudience {
+		return nil, trace.AccessDenied("SAML assertion audience %q does not match expected audience %q",
+			assertion.Conditions.AudienceRestriction.Audience, expectedAudience)
+	}
+
+	attributes := make(map[string][]string, len(assertion.AttributeStatement.Attributes))
+	for _, attr := range assertion.AttributeStatement.Attributes {
+		attributes[attr.Name] = attr.Values
+	}
+	return attributes, nil
+}
+
+// verifyAssertionSignature checks that digest and signature in
+// assertion.Signature were produced by cert's private key over the
+// assertion body (with the Signature element itself excluded, as
+// XML-DSig's enveloped-signature transform requires).
+func verifyAssertionSignature(cert *x509.Certificate, assertion samlAssertion) error {
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return trace.BadParameter("IdP signing certificate does not use an RSA key")
+	}
+
+	signedBody := stripSignatureElement(assertion.InnerXML)
+
+	digest := sha256.Sum256(signedBody)
+	wantDigest, err := base64.StdEncoding.DecodeString(assertion.Signature.SignedInfo.DigestValue)
+	if err != nil {
+		return trace.BadParameter("invalid digest value: %v", err)
+	}
+	if !bytes.Equal(digest[:], wantDigest) {
+		return trace.BadParameter("assertion digest does not match SignedInfo")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(assertion.Signature.SignatureValue)
+	if err != nil {
+		return trace.BadParameter("invalid signature value: %v", err)
+	}
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+		return trace.Wrap(err, "signature does not match digest")
+	}
+	return nil
+}
+
+// stripSignatureElement removes the <Signature>...</Signature> block from
+// raw, the enveloped-signature transform XML-DSig requires before the
+// digest covering the rest of the assertion is computed.
+func stripSignatureElement(raw []byte) []byte {
+	start := bytes.Index(raw, []byte("<Signature"))
+	if start == -1 {
+		return raw
+	}
+	end := bytes.Index(raw[start:], []byte("</Signature>"))
+	if end == -1 {
+		return raw
+	}
+	end += start + len("</Signature>")
+	out := make([]byte, 0, len(raw)-(end-start))
+	out = append(out, raw[:start]...)
+	out = append(out, raw[end:]...)
+	return out
+}
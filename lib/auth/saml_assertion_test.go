@@ -0,0 +1,159 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/gravitational/teleport/lib/services"
+
+	. "gopkg.in/check.v1"
+)
+
+type SAMLAssertionSuite struct{}
+
+var _ = Suite(&SAMLAssertionSuite{})
+
+func selfSignedSAMLCert(c *C, priv *rsa.PrivateKey) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	c.Assert(err, IsNil)
+	cert, err := x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+	return cert
+}
+
+func samlEntityDescriptorFor(cert *x509.Certificate) string {
+	certB64 := base64.StdEncoding.EncodeToString(cert.Raw)
+	return fmt.Sprintf(`<EntityDescriptor><IDPSSODescriptor><KeyDescriptor use="signing"><KeyInfo><X509Data><X509Certificate>%s</X509Certificate></X509Data></KeyInfo></KeyDescriptor></IDPSSODescriptor></EntityDescriptor>`, certB64)
+}
+
+// buildSignedSAMLResponse hand-builds a minimal, signed <Response> the
+// same way validateSAMLAssertion expects to parse one: sign over the
+// Conditions+AttributeStatement body, then wrap it with a Signature
+// element carrying that digest/signature.
+func buildSignedSAMLResponse(c *C, priv *rsa.PrivateKey, audience string, notBefore, notOnOrAfter time.Time) string {
+	body := fmt.Sprintf(`<Conditions NotBefore=%q NotOnOrAfter=%q><AudienceRestriction><Audience>%s</Audience></AudienceRestriction></Conditions><AttributeStatement><Attribute Name="groups"><AttributeValue>admins</AttributeValue></Attribute></AttributeStatement>`,
+		notBefore.Format(time.RFC3339), notOnOrAfter.Format(time.RFC3339), audience)
+
+	sum := sha256.Sum256([]byte(body))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, sum[:])
+	c.Assert(err, IsNil)
+
+	assertion := fmt.Sprintf(`<Assertion>%s<Signature><SignedInfo><Reference><DigestValue>%s</DigestValue></Reference></SignedInfo><SignatureValue>%s</SignatureValue></Signature></Assertion>`,
+		body, base64.StdEncoding.EncodeToString(sum[:]), base64.StdEncoding.EncodeToString(sig))
+
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`<Response>%s</Response>`, assertion)))
+}
+
+func testSAMLConnector(entityDescriptor, audience string) services.SAMLConnector {
+	return services.NewSAMLConnector("test", services.SAMLConnectorSpecV2{
+		EntityDescriptor:         entityDescriptor,
+		SSOURL:                   "https://idp.example.com/sso",
+		AssertionConsumerService: "https://teleport.example.com/acs",
+		Audience:                 audience,
+	})
+}
+
+func (s *SAMLAssertionSuite) TestValidateSAMLAssertionAcceptsSignedAttributes(c *C) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	cert := selfSignedSAMLCert(c, priv)
+	connector := testSAMLConnector(samlEntityDescriptorFor(cert), "https://teleport.example.com")
+
+	now := time.Now()
+	raw := buildSignedSAMLResponse(c, priv, "https://teleport.example.com", now.Add(-time.Minute), now.Add(time.Hour))
+
+	attrs, err := validateSAMLAssertion(connector, raw, now)
+	c.Assert(err, IsNil)
+	c.Assert(attrs["groups"], DeepEquals, []string{"admins"})
+}
+
+func (s *SAMLAssertionSuite) TestValidateSAMLAssertionRejectsTamperedAttributes(c *C) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	cert := selfSignedSAMLCert(c, priv)
+	connector := testSAMLConnector(samlEntityDescriptorFor(cert), "https://teleport.example.com")
+
+	now := time.Now()
+	raw := buildSignedSAMLResponse(c, priv, "https://teleport.example.com", now.Add(-time.Minute), now.Add(time.Hour))
+	decoded, err := base64.StdEncoding.DecodeString(raw)
+	c.Assert(err, IsNil)
+	tampered := bytes.Replace(decoded, []byte("admins"), []byte("superadmin"), 1)
+	raw = base64.StdEncoding.EncodeToString(tampered)
+
+	_, err = validateSAMLAssertion(connector, raw, now)
+	c.Assert(err, NotNil)
+}
+
+func (s *SAMLAssertionSuite) TestValidateSAMLAssertionRejectsWrongAudience(c *C) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	cert := selfSignedSAMLCert(c, priv)
+	connector := testSAMLConnector(samlEntityDescriptorFor(cert), "https://teleport.example.com")
+
+	now := time.Now()
+	raw := buildSignedSAMLResponse(c, priv, "https://someone-else.example.com", now.Add(-time.Minute), now.Add(time.Hour))
+
+	_, err = validateSAMLAssertion(connector, raw, now)
+	c.Assert(err, NotNil)
+}
+
+func (s *SAMLAssertionSuite) TestValidateSAMLAssertionRejectsExpired(c *C) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	cert := selfSignedSAMLCert(c, priv)
+	connector := testSAMLConnector(samlEntityDescriptorFor(cert), "https://teleport.example.com")
+
+	now := time.Now()
+	raw := buildSignedSAMLResponse(c, priv, "https://teleport.example.com", now.Add(-2*time.Hour), now.Add(-time.Hour))
+
+	_, err = validateSAMLAssertion(connector, raw, now)
+	c.Assert(err, NotNil)
+}
+
+func (s *SAMLAssertionSuite) TestValidateSAMLAssertionRejectsWrongSigner(c *C) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	otherPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	cert := selfSignedSAMLCert(c, priv)
+	connector := testSAMLConnector(samlEntityDescriptorFor(cert), "https://teleport.example.com")
+
+	now := time.Now()
+	// signed by otherPriv, but the connector's entity descriptor only
+	// trusts priv's certificate.
+	raw := buildSignedSAMLResponse(c, otherPriv, "https://teleport.example.com", now.Add(-time.Minute), now.Add(time.Hour))
+
+	_, err = validateSAMLAssertion(connector, raw, now)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+)
+
+type ConfDSuite struct{}
+
+var _ = check.Suite(&ConfDSuite{})
+
+func (s *ConfDSuite) TestMergeAppendsAdditiveSlices(c *check.C) {
+	base := &FileConfig{}
+	base.Auth.StaticTokens = []StaticToken{"proxy,node:aaaa"}
+
+	overlay := &FileConfig{}
+	overlay.Auth.StaticTokens = []StaticToken{"auth:bbbb"}
+	overlay.SSH.Commands = []CommandLabel{{Name: "os", Command: []string{"uname", "-o"}}}
+
+	mergeFileConfig(base, overlay)
+
+	c.Assert(base.Auth.StaticTokens, check.DeepEquals, []StaticToken{"proxy,node:aaaa", "auth:bbbb"})
+	c.Assert(base.SSH.Commands, check.HasLen, 1)
+}
+
+func (s *ConfDSuite) TestReadFromFileWithConfDMergesFragments(c *check.C) {
+	dir := c.MkDir()
+	main := filepath.Join(dir, "teleport.yaml")
+	err := ioutil.WriteFile(main, []byte(`
+teleport:
+  nodename: base-node
+auth_service:
+  static_tokens:
+  - "proxy,node:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+`), 0660)
+	c.Assert(err, check.IsNil)
+
+	confd := filepath.Join(dir, DropInDirName)
+	c.Assert(os.MkdirAll(confd, 0770), check.IsNil)
+	err = ioutil.WriteFile(filepath.Join(confd, "10-extra-tokens.yaml"), []byte(`
+auth_service:
+  static_tokens:
+  - "auth:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+`), 0660)
+	c.Assert(err, check.IsNil)
+
+	fc, err := ReadFromFileWithConfD(main, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(fc.NodeName, check.Equals, "base-node")
+	c.Assert(fc.Auth.StaticTokens, check.HasLen, 2)
+}
+
+func (s *ConfDSuite) TestFindConfigurationConflicts(c *check.C) {
+	fc, err := ReadConfig(bytes.NewBufferString(`
+teleport:
+  advertise_ip: 10.10.10.1
+`))
+	c.Assert(err, check.IsNil)
+
+	err = findConfigurationConflicts(*fc, map[string]string{"advertise-ip": "10.0.0.1"})
+	c.Assert(err, check.NotNil)
+	c.Assert(err, check.ErrorMatches, ".*advertise_ip: \\(from flag: 10.0.0.1, from file: 10.10.10.1\\).*")
+
+	err = findConfigurationConflicts(*fc, map[string]string{"advertise-ip": "10.10.10.1"})
+	c.Assert(err, check.IsNil)
+}
@@ -23,6 +23,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
 	"time"
 
@@ -361,6 +362,62 @@ func (s *ConfigTestSuite) TestApplyConfig(c *check.C) {
 	c.Assert(cfg.Proxy.ReverseTunnelListenAddr.FullAddress(), check.Equals, "tcp://tunnelhost:1001")
 }
 
+// TestHotReload verifies that sending a real SIGHUP to the process
+// re-reads the config file from disk, applies the sections that can be
+// hot-swapped (here, static tokens), and leaves the SSH listener
+// untouched.
+func (s *ConfigTestSuite) TestHotReload(c *check.C) {
+	configPath := filepath.Join(c.MkDir(), "teleport.yaml")
+	c.Assert(ioutil.WriteFile(configPath, []byte(SmallConfigString), 0660), check.IsNil)
+
+	conf, err := ReadFromFileWithConfD(configPath, "")
+	c.Assert(err, check.IsNil)
+
+	cfg := service.MakeDefaultConfig()
+	err = ApplyFileConfig(conf, cfg)
+	c.Assert(err, check.IsNil)
+
+	process, err := service.NewTeleport(cfg)
+	c.Assert(err, check.IsNil)
+
+	WatchAndReload(process, configPath, "")
+
+	updatedYAML := SmallConfigString + "\n  static_tokens:\n  - \"zzz:cccccccccccccccccccccccccccccccccc\"\n"
+	c.Assert(ioutil.WriteFile(configPath, []byte(updatedYAML), 0660), check.IsNil)
+
+	updated, err := ReadFromFileWithConfD(configPath, "")
+	c.Assert(err, check.IsNil)
+	newCfg := service.MakeDefaultConfig()
+	err = ApplyFileConfig(updated, newCfg)
+	c.Assert(err, check.IsNil)
+
+	// WatchAndReload registers its signal.Notify asynchronously, so retry
+	// the kill until it lands instead of racing a single delivery attempt.
+	stopKill := make(chan struct{})
+	defer close(stopKill)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+			case <-stopKill:
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-process.Reloaded():
+	case <-time.After(time.Second):
+		c.Fatal("timed out waiting for SIGHUP-triggered reload to complete")
+	}
+
+	c.Assert(process.Config.Auth.StaticTokens, check.DeepEquals, newCfg.Auth.StaticTokens)
+	c.Assert(process.Config.SSH.Addr, check.DeepEquals, cfg.SSH.Addr)
+}
+
 // TestLegacyU2FTransformation ensures that the legacy format for U2F gets transformed
 // into the new format that we are using now for backward compatibility.
 func (s *ConfigTestSuite) TestLegacyU2FTransformation(c *check.C) {
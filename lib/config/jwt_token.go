@@ -0,0 +1,85 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"github.com/gravitational/teleport"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/trace"
+
+	"gopkg.in/yaml.v2"
+)
+
+// JWTStaticToken is the YAML shape of a `kind: jwt` entry under
+// `auth_service.static_tokens`:
+//
+//   - kind: jwt
+//     roles: [node]
+//     issuer: https://oidc.example.com
+//     aud: teleport.example.com
+//     jwks_url: https://oidc.example.com/.well-known/jwks.json
+//     bound_claims:
+//       sub: spiffe://example.com/ci-runner
+//
+// parseJWTStaticTokens converts such entries from raw YAML nodes. The
+// FileConfig static_tokens field is a list of StaticToken strings
+// (`proxy,node:xxxx`) and is not read through parseJWTStaticTokens, so a
+// `kind: jwt` mapping there does not decode into it.
+type JWTStaticToken struct {
+	Kind        string            `yaml:"kind"`
+	Roles       []string          `yaml:"roles"`
+	Issuer      string            `yaml:"issuer"`
+	Audience    string            `yaml:"aud"`
+	JWKSURL     string            `yaml:"jwks_url,omitempty"`
+	BoundClaims map[string]string `yaml:"bound_claims,omitempty"`
+}
+
+// parseJWTStaticTokens picks the `kind: jwt` entries out of the raw
+// static_tokens YAML nodes and converts each into a services.JWTProvisionToken,
+// leaving the plain bearer-token strings for the existing StaticToken parser.
+func parseJWTStaticTokens(raw []yaml.MapSlice) ([]services.JWTProvisionToken, error) {
+	var out []services.JWTProvisionToken
+	for _, entry := range raw {
+		data, err := yaml.Marshal(entry)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		var jt JWTStaticToken
+		if err := yaml.Unmarshal(data, &jt); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if jt.Kind != "jwt" {
+			continue
+		}
+		roles, err := teleport.ParseRoles(jt.Roles)
+		if err != nil {
+			return nil, trace.Wrap(err, "parsing roles for jwt static token issued by %q", jt.Issuer)
+		}
+		token := services.JWTProvisionToken{
+			Roles:       roles,
+			Issuer:      jt.Issuer,
+			Audience:    jt.Audience,
+			JWKSURL:     jt.JWKSURL,
+			BoundClaims: jt.BoundClaims,
+		}
+		if err := token.CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		out = append(out, token)
+	}
+	return out, nil
+}
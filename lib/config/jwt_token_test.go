@@ -0,0 +1,48 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+)
+
+type JWTTokenSuite struct{}
+
+var _ = check.Suite(&JWTTokenSuite{})
+
+func (s *JWTTokenSuite) TestParseJWTStaticTokens(c *check.C) {
+	input := `
+- kind: jwt
+  roles: [node]
+  issuer: https://oidc.example.com
+  aud: teleport.example.com
+  bound_claims:
+    sub: spiffe://example.com/ci-runner
+`
+	var raw []yaml.MapSlice
+	err := yaml.Unmarshal([]byte(input), &raw)
+	c.Assert(err, check.IsNil)
+
+	tokens, err := parseJWTStaticTokens(raw)
+	c.Assert(err, check.IsNil)
+	c.Assert(tokens, check.HasLen, 1)
+	c.Assert(tokens[0].Issuer, check.Equals, "https://oidc.example.com")
+	c.Assert(tokens[0].Audience, check.Equals, "teleport.example.com")
+	c.Assert(tokens[0].JWKSURL, check.Equals, "https://oidc.example.com/.well-known/jwks.json")
+	c.Assert(tokens[0].BoundClaims, check.DeepEquals, map[string]string{"sub": "spiffe://example.com/ci-runner"})
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+// Recovery is the `recovery:` top-level section of FileConfig. Modeled
+// on Consul's gRPC recovery interceptor, it holds the settings for the
+// panic-recovery wrappers in lib/service (WrapHTTPHandler and
+// WrapChannelHandler). Nothing in this package converts a Recovery into
+// the service.RecoveryConfig those wrappers take, and no SSH channel
+// handler, reverse-tunnel accept loop or auth-API HTTP handler is wrapped
+// with them.
+type Recovery struct {
+	// EnabledFlag follows the same "yes/no/true/false" convention used by
+	// the *_service sections; empty means enabled.
+	EnabledFlag string `yaml:"enabled,omitempty"`
+	// StackTrace includes the full goroutine stack in the log line for a
+	// recovered panic, not just the panic value.
+	StackTrace bool `yaml:"stack_trace,omitempty"`
+	// Metric is the counter name MetricName reports. The counter that
+	// lib/service increments on a recovered panic is always registered
+	// as teleport_panics_total, whatever this is set to.
+	Metric string `yaml:"metric,omitempty"`
+}
+
+// Enabled returns true unless EnabledFlag was explicitly set to a falsy
+// value, matching Configured()/Enabled() on the other *_service sections.
+func (r Recovery) Enabled() bool {
+	return !isFalsyBool(r.EnabledFlag)
+}
+
+// MetricName returns the configured counter name, defaulting to
+// "teleport_panics_total" to match the metric Consul-style deployments
+// already scrape for other components.
+func (r Recovery) MetricName() string {
+	if r.Metric == "" {
+		return "teleport_panics_total"
+	}
+	return r.Metric
+}
+
+// isFalsyBool treats an empty string as "unset, so true", accepts
+// strconv.ParseBool's true spellings plus YAML's "yes" as true, and treats
+// every other value (including unrecognized ones) as false.
+func isFalsyBool(s string) bool {
+	switch s {
+	case "", "1", "t", "T", "true", "TRUE", "True", "yes", "Yes", "YES":
+		return false
+	default:
+		return true
+	}
+}
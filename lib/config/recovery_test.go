@@ -0,0 +1,43 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+)
+
+type RecoverySuite struct{}
+
+var _ = check.Suite(&RecoverySuite{})
+
+func (s *RecoverySuite) TestRecoveryConfigDefaults(c *check.C) {
+	var r Recovery
+	err := yaml.Unmarshal([]byte(""), &r)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Enabled(), check.Equals, true)
+	c.Assert(r.MetricName(), check.Equals, "teleport_panics_total")
+}
+
+func (s *RecoverySuite) TestRecoveryConfigParsing(c *check.C) {
+	var r Recovery
+	err := yaml.Unmarshal([]byte("enabled: no\nstack_trace: true\nmetric: custom_panics\n"), &r)
+	c.Assert(err, check.IsNil)
+	c.Assert(r.Enabled(), check.Equals, false)
+	c.Assert(r.StackTrace, check.Equals, true)
+	c.Assert(r.MetricName(), check.Equals, "custom_panics")
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"github.com/gravitational/teleport/lib/service"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// WatchAndReload wires service.SighupCh to process: every SIGHUP,
+// configPath (plus any conf.d fragments under dropInDir) is re-read and
+// re-applied exactly as it is at startup, and the resulting Config is
+// handed to process.ApplyReload. This lives in lib/config rather than
+// lib/service because reading and applying FileConfig already depends on
+// this package; lib/service only knows how to apply an already-parsed
+// Config.
+func WatchAndReload(process *service.TeleportProcess, configPath, dropInDir string) {
+	go func() {
+		for range service.SighupCh() {
+			fc, err := ReadFromFileWithConfD(configPath, dropInDir)
+			if err != nil {
+				log.Warningf("reload: failed to read %v: %v", configPath, err)
+				continue
+			}
+
+			newConfig := service.MakeDefaultConfig()
+			if err := ApplyFileConfig(fc, newConfig); err != nil {
+				log.Warningf("reload: failed to apply config: %v", err)
+				continue
+			}
+
+			if err := process.ApplyReload(newConfig); err != nil {
+				log.Warningf("reload: failed to apply reload: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// secretDuration unmarshals a YAML duration string (e.g. "30s") the way
+// secretRef.reload_interval is written in a config file.
+type secretDuration time.Duration
+
+func (d secretDuration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d *secretDuration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return trace.Wrap(err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return trace.Wrap(err, "parsing reload_interval %q", s)
+	}
+	*d = secretDuration(parsed)
+	return nil
+}
+
+// secretRef is a credential-bearing YAML field that can either hold its
+// value inline or point at a file to read it from, following the pattern
+// Prometheus uses for `bearer_token_file`/`password_file`. It unmarshals
+// from either a bare scalar (`token: "abc"`) or a mapping
+// (`token: {file: /path, reload_interval: 30s}`).
+type secretRef struct {
+	// Value is the literal secret, set when the YAML node was a scalar.
+	Value string `yaml:"-"`
+	// File is the path to read the secret from.
+	File string `yaml:"file,omitempty"`
+	// ReloadInterval controls how often the watcher re-reads File; zero
+	// means read once at startup and never again.
+	ReloadInterval secretDuration `yaml:"reload_interval,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler so a secretRef can be written
+// either as a plain string or as the {file, reload_interval} form.
+func (s *secretRef) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var scalar string
+	if err := unmarshal(&scalar); err == nil {
+		s.Value = scalar
+		return nil
+	}
+
+	type plain secretRef
+	var p plain
+	if err := unmarshal(&p); err != nil {
+		return trace.Wrap(err)
+	}
+	*s = secretRef(p)
+	return nil
+}
+
+// Resolve returns the current value of the secret: Value if it was set
+// inline, or the contents of File otherwise.
+func (s *secretRef) Resolve() (string, error) {
+	if s.File == "" {
+		return s.Value, nil
+	}
+	data, err := ioutil.ReadFile(s.File)
+	if err != nil {
+		return "", trace.Wrap(err, "reading secret file %q", s.File)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// IsFileBacked reports whether this secret should be watched for changes.
+func (s *secretRef) IsFileBacked() bool {
+	return s.File != "" && s.ReloadInterval.Duration() > 0
+}
+
+// secretWatcher periodically re-reads one file-backed secretRef and
+// invokes onChange with the new value whenever it differs from the last
+// one seen. It's the mechanism that lets Vault Agent / cert-manager style
+// sidecars rotate credentials in place without a Teleport restart.
+type secretWatcher struct {
+	mu       sync.Mutex
+	interval time.Duration
+	ref      *secretRef
+	last     string
+	onChange func(newValue string)
+	stopCh   chan struct{}
+}
+
+// newSecretWatcher starts watching ref at its configured ReloadInterval,
+// calling onChange whenever the resolved value changes. It returns nil if
+// ref isn't file-backed or has no reload interval configured.
+func newSecretWatcher(ref *secretRef, onChange func(string)) (*secretWatcher, error) {
+	if !ref.IsFileBacked() {
+		return nil, nil
+	}
+	initial, err := ref.Resolve()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	w := &secretWatcher{
+		interval: ref.ReloadInterval.Duration(),
+		ref:      ref,
+		last:     initial,
+		onChange: onChange,
+		stopCh:   make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *secretWatcher) loop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.poll()
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+func (w *secretWatcher) poll() {
+	value, err := w.ref.Resolve()
+	if err != nil {
+		// a transient read error (e.g. the sidecar is mid-rewrite) is not
+		// fatal; keep serving the last known-good value and try again on
+		// the next tick.
+		return
+	}
+	w.mu.Lock()
+	changed := value != w.last
+	w.last = value
+	w.mu.Unlock()
+	if changed {
+		w.onChange(value)
+	}
+}
+
+// Stop ends the watch loop.
+func (w *secretWatcher) Stop() {
+	close(w.stopCh)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+)
+
+type SecretRefSuite struct{}
+
+var _ = check.Suite(&SecretRefSuite{})
+
+func (s *SecretRefSuite) TestUnmarshalInline(c *check.C) {
+	var ref secretRef
+	err := yaml.Unmarshal([]byte(`"xxxyyy"`), &ref)
+	c.Assert(err, check.IsNil)
+	c.Assert(ref.Value, check.Equals, "xxxyyy")
+
+	value, err := ref.Resolve()
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "xxxyyy")
+	c.Assert(ref.IsFileBacked(), check.Equals, false)
+}
+
+func (s *SecretRefSuite) TestUnmarshalFileBacked(c *check.C) {
+	var ref secretRef
+	err := yaml.Unmarshal([]byte("file: /etc/teleport/token\nreload_interval: 30s\n"), &ref)
+	c.Assert(err, check.IsNil)
+	c.Assert(ref.File, check.Equals, "/etc/teleport/token")
+	c.Assert(ref.ReloadInterval.Duration(), check.Equals, 30*time.Second)
+	c.Assert(ref.IsFileBacked(), check.Equals, true)
+}
+
+func (s *SecretRefSuite) TestWatcherPicksUpChange(c *check.C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "token")
+	c.Assert(ioutil.WriteFile(path, []byte("initial-token"), 0600), check.IsNil)
+
+	ref := &secretRef{File: path, ReloadInterval: secretDuration(10 * time.Millisecond)}
+
+	updates := make(chan string, 4)
+	w, err := newSecretWatcher(ref, func(v string) { updates <- v })
+	c.Assert(err, check.IsNil)
+	defer w.Stop()
+
+	c.Assert(ioutil.WriteFile(path, []byte("rotated-token"), 0600), check.IsNil)
+
+	select {
+	case v := <-updates:
+		c.Assert(v, check.Equals, "rotated-token")
+	case <-time.After(time.Second):
+		c.Fatal("timed out waiting for secret watcher to notice the rotated file")
+	}
+}
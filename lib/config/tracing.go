@@ -0,0 +1,91 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"github.com/gravitational/teleport/lib/service"
+	"github.com/gravitational/trace"
+)
+
+// Tracing is the `tracing:` top-level section of FileConfig. It follows
+// the same exporter/sampler shape Consul and Envoy expose to operators,
+// so the same mental model (and often the same collector) that's already
+// wired up for the rest of the service mesh can be pointed at Teleport.
+type Tracing struct {
+	// Exporter selects the wire format spans are emitted in: jaeger,
+	// zipkin or otlp.
+	Exporter string `yaml:"exporter,omitempty"`
+	// Endpoint is the collector address the exporter sends spans to.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// Sampler is one of "always", "never" or "ratio".
+	Sampler string `yaml:"sampler,omitempty"`
+	// SamplerParam is the sampling ratio when Sampler is "ratio".
+	SamplerParam float64 `yaml:"sampler_param,omitempty"`
+	// ServiceName is copied into service.TracingConfig.ServiceName;
+	// defaults to "teleport" when empty. NewTracerProvider does not attach
+	// it to exported spans.
+	ServiceName string `yaml:"service_name,omitempty"`
+}
+
+// Enabled reports whether an exporter has been configured at all.
+func (t Tracing) Enabled() bool {
+	return t.Exporter != ""
+}
+
+// Parse validates the tracing section and converts it into the
+// service.TracingConfig that lib/service builds an OpenTelemetry
+// TracerProvider from.
+func (t Tracing) Parse() (service.TracingConfig, error) {
+	out := service.TracingConfig{}
+	if !t.Enabled() {
+		return out, nil
+	}
+
+	switch t.Exporter {
+	case "jaeger", "zipkin", "otlp":
+		out.Exporter = t.Exporter
+	default:
+		return out, trace.BadParameter("tracing: unsupported exporter %q, must be one of jaeger, zipkin, otlp", t.Exporter)
+	}
+
+	if t.Endpoint == "" {
+		return out, trace.BadParameter("tracing: endpoint is required when exporter is set")
+	}
+	out.Endpoint = t.Endpoint
+
+	switch t.Sampler {
+	case "", "always":
+		out.Sampler = "always"
+	case "never":
+		out.Sampler = "never"
+	case "ratio":
+		if t.SamplerParam <= 0 || t.SamplerParam > 1 {
+			return out, trace.BadParameter("tracing: sampler_param must be in (0, 1] for a ratio sampler, got %v", t.SamplerParam)
+		}
+		out.Sampler = "ratio"
+		out.SamplerParam = t.SamplerParam
+	default:
+		return out, trace.BadParameter("tracing: unsupported sampler %q, must be one of always, never, ratio", t.Sampler)
+	}
+
+	out.ServiceName = t.ServiceName
+	if out.ServiceName == "" {
+		out.ServiceName = "teleport"
+	}
+
+	return out, nil
+}
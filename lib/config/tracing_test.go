@@ -0,0 +1,90 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+)
+
+type TracingSuite struct{}
+
+var _ = check.Suite(&TracingSuite{})
+
+func (s *TracingSuite) TestTracingConfig(c *check.C) {
+	tests := []struct {
+		comment      string
+		input        string
+		wantSampler  string
+		wantExporter string
+	}{
+		{
+			comment: "jaeger with default sampler",
+			input: `
+exporter: jaeger
+endpoint: http://jaeger-collector:14268/api/traces
+service_name: auth
+`,
+			wantSampler:  "always",
+			wantExporter: "jaeger",
+		},
+		{
+			comment: "zipkin with ratio sampler",
+			input: `
+exporter: zipkin
+endpoint: http://zipkin:9411/api/v2/spans
+sampler: ratio
+sampler_param: 0.01
+`,
+			wantSampler:  "ratio",
+			wantExporter: "zipkin",
+		},
+		{
+			comment: "otlp never sample",
+			input: `
+exporter: otlp
+endpoint: otel-collector:4317
+sampler: never
+`,
+			wantSampler:  "never",
+			wantExporter: "otlp",
+		},
+	}
+
+	for _, tt := range tests {
+		comment := check.Commentf(tt.comment)
+
+		var tracing Tracing
+		err := yaml.Unmarshal([]byte(tt.input), &tracing)
+		c.Assert(err, check.IsNil, comment)
+
+		parsed, err := tracing.Parse()
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(parsed.Exporter, check.Equals, tt.wantExporter, comment)
+		c.Assert(parsed.Sampler, check.Equals, tt.wantSampler, comment)
+	}
+}
+
+func (s *TracingSuite) TestTracingConfigRejectsUnknownExporter(c *check.C) {
+	var tracing Tracing
+	err := yaml.Unmarshal([]byte("exporter: datadog\nendpoint: foo:1234\n"), &tracing)
+	c.Assert(err, check.IsNil)
+
+	_, err = tracing.Parse()
+	c.Assert(err, check.NotNil)
+	c.Assert(err, check.ErrorMatches, ".*unsupported exporter.*")
+}
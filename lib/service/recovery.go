@@ -0,0 +1,96 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// RecoveryConfig is the parsed form of FileConfig's `recovery:` section.
+type RecoveryConfig struct {
+	Enabled    bool
+	StackTrace bool
+	Metric     string
+}
+
+// panicsTotal counts every panic recovered by WrapHTTPHandler/WrapChannelHandler,
+// labeled by the component and handler that panicked.
+var panicsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "teleport_panics_total",
+		Help: "Number of panics recovered from RPC handlers, by component and handler",
+	},
+	[]string{"component", "handler"},
+)
+
+func init() {
+	prometheus.MustRegister(panicsTotal)
+}
+
+// recoverPanic returns a function that recovers a panic, logs it (with a
+// stack trace when cfg.StackTrace is set), increments panicsTotal and then
+// calls onPanic. The returned function must be deferred directly, e.g.
+// `defer recoverPanic(cfg, "ssh", "session", func() { err = rejectErr })()`.
+func recoverPanic(cfg RecoveryConfig, component, handler string, onPanic func()) func() {
+	return func() {
+		if rec := recover(); rec != nil {
+			fields := log.Fields{"component": component, "handler": handler, "panic": rec}
+			if cfg.StackTrace {
+				fields["stack"] = string(debug.Stack())
+			}
+			log.WithFields(fields).Error("recovered from panic in RPC handler")
+			panicsTotal.WithLabelValues(component, handler).Inc()
+			if onPanic != nil {
+				onPanic()
+			}
+		}
+	}
+}
+
+// WrapHTTPHandler wraps an auth-API HTTP handler so a panic is recovered,
+// logged, counted and turned into a clean 500 instead of tearing down the
+// server.
+func WrapHTTPHandler(cfg RecoveryConfig, handler string, next http.HandlerFunc) http.HandlerFunc {
+	if !cfg.Enabled {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer recoverPanic(cfg, "auth", handler, func() {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		})()
+		next(w, r)
+	}
+}
+
+// WrapChannelHandler runs fn with panic recovery installed; if fn panics,
+// the panic is logged and counted and rejectErr is returned to the caller
+// instead of propagating, so a single malformed SSH channel request can't
+// tear down the whole accept loop.
+func WrapChannelHandler(cfg RecoveryConfig, component, handler string, rejectErr error, fn func()) (recovered error) {
+	if !cfg.Enabled {
+		fn()
+		return nil
+	}
+	defer recoverPanic(cfg, component, handler, func() { recovered = rejectErr })()
+	fn()
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	. "gopkg.in/check.v1"
+)
+
+func TestRecovery(t *testing.T) { TestingT(t) }
+
+type RecoverySuite struct{}
+
+var _ = Suite(&RecoverySuite{})
+
+func (s *RecoverySuite) TestWrapChannelHandlerRecoversPanic(c *C) {
+	cfg := RecoveryConfig{Enabled: true}
+	rejectErr := errors.New("channel rejected")
+	before := testutil.ToFloat64(panicsTotal.WithLabelValues("ssh", "session"))
+
+	err := WrapChannelHandler(cfg, "ssh", "session", rejectErr, func() {
+		panic("boom")
+	})
+	c.Assert(err, Equals, rejectErr)
+	c.Assert(testutil.ToFloat64(panicsTotal.WithLabelValues("ssh", "session")), Equals, before+1)
+}
+
+func (s *RecoverySuite) TestWrapChannelHandlerDisabledPropagatesPanic(c *C) {
+	cfg := RecoveryConfig{Enabled: false}
+
+	defer func() {
+		c.Assert(recover(), NotNil)
+	}()
+	WrapChannelHandler(cfg, "ssh", "session", errors.New("unused"), func() {
+		panic("boom")
+	})
+}
+
+func (s *RecoverySuite) TestWrapHTTPHandlerReturns500OnPanic(c *C) {
+	cfg := RecoveryConfig{Enabled: true}
+	before := testutil.ToFloat64(panicsTotal.WithLabelValues("auth", "webapi"))
+	handler := WrapHTTPHandler(cfg, "webapi", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	c.Assert(rec.Code, Equals, http.StatusInternalServerError)
+	c.Assert(testutil.ToFloat64(panicsTotal.WithLabelValues("auth", "webapi")), Equals, before+1)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+
+	"github.com/gravitational/trace"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// restartOnlySections names the Config fields ApplyReload never touches
+// because swapping them on a live process would leave it in an
+// inconsistent state (listener sockets, storage backend, data dir).
+// ApplyReload hot-swaps static tokens, CAs, reverse tunnels, U2F, the
+// limiters' max connections and max users, and log severity; the
+// limiters' request rates are not updated on reload. changedRestartOnlySections
+// compares old against newConfig field by field so a changed listener
+// address is surfaced by name instead of silently doing nothing.
+var restartOnlySections = []string{
+	"Auth.Addr",
+	"SSH.Addr",
+	"Proxy.Addr",
+	"DataDir",
+	"Storage",
+}
+
+// changedRestartOnlySections returns the names (drawn from
+// restartOnlySections) of every field that differs between old and
+// newConfig, for ApplyReload to log as requiring a restart.
+func changedRestartOnlySections(old, newConfig *Config) []string {
+	var changed []string
+	if !reflect.DeepEqual(old.Auth.Addr, newConfig.Auth.Addr) {
+		changed = append(changed, "Auth.Addr")
+	}
+	if !reflect.DeepEqual(old.SSH.Addr, newConfig.SSH.Addr) {
+		changed = append(changed, "SSH.Addr")
+	}
+	if !reflect.DeepEqual(old.Proxy.Addr, newConfig.Proxy.Addr) {
+		changed = append(changed, "Proxy.Addr")
+	}
+	if old.DataDir != newConfig.DataDir {
+		changed = append(changed, "DataDir")
+	}
+	if old.Storage.Type != newConfig.Storage.Type {
+		changed = append(changed, "Storage")
+	}
+	return changed
+}
+
+// SighupCh returns a channel that receives a value every time the process
+// gets a SIGHUP. Modeled on the reload pattern used by Vault's proxy:
+// callers range over the channel and call ApplyReload with a freshly
+// parsed configuration.
+func SighupCh() chan os.Signal {
+	sighupCh := make(chan os.Signal, 1)
+	signal.Notify(sighupCh, syscall.SIGHUP)
+	return sighupCh
+}
+
+// ApplyReload pushes the sections of newConfig that can be hot-swapped
+// into the running auth server and updates the process' in-memory
+// Config, then signals Reloaded(). Listener addresses, the storage
+// backend and the data dir are left untouched and merely logged so an
+// operator knows those edits still require a restart.
+func (process *TeleportProcess) ApplyReload(newConfig *Config) error {
+	for _, section := range changedRestartOnlySections(process.Config, newConfig) {
+		log.Warningf("%v changed in config but requires a restart to take effect; ignoring on reload", section)
+	}
+
+	if authServer := process.getLocalAuth(); authServer != nil {
+		if err := authServer.SetStaticTokens(newConfig.Auth.StaticTokens); err != nil {
+			return trace.Wrap(err)
+		}
+		for _, ca := range newConfig.Auth.Authorities {
+			if err := authServer.UpsertCertAuthority(ca); err != nil {
+				return trace.Wrap(err)
+			}
+		}
+		for _, tun := range newConfig.ReverseTunnels {
+			if err := authServer.UpsertReverseTunnel(tun); err != nil {
+				return trace.Wrap(err)
+			}
+		}
+		if newConfig.Auth.U2F.Enabled() {
+			authServer.SetU2F(newConfig.Auth.U2F)
+		}
+	}
+
+	for _, lim := range process.getLimiters() {
+		lim.SetMaxConnections(newConfig.Limits.MaxConnections)
+		lim.SetMaxNumberOfUsers(newConfig.Limits.MaxUsers)
+	}
+
+	if newConfig.Logger.Severity != process.Config.Logger.Severity {
+		level, err := log.ParseLevel(newConfig.Logger.Severity)
+		if err != nil {
+			return trace.Wrap(err, "parsing log severity %q", newConfig.Logger.Severity)
+		}
+		log.SetLevel(level)
+	}
+
+	process.Config.Auth.StaticTokens = newConfig.Auth.StaticTokens
+	process.Config.Auth.Authorities = newConfig.Auth.Authorities
+	process.Config.ReverseTunnels = newConfig.ReverseTunnels
+	process.Config.Limits = newConfig.Limits
+	process.Config.Logger = newConfig.Logger
+
+	select {
+	case process.reloadedCh <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Reloaded returns a channel that receives a value every time ApplyReload
+// completes. Tests use this to synchronize on a SIGHUP-triggered reload
+// instead of sleeping.
+func (process *TeleportProcess) Reloaded() <-chan struct{} {
+	return process.reloadedCh
+}
+
@@ -0,0 +1,81 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"github.com/gravitational/teleport"
+	"github.com/gravitational/trace"
+)
+
+// JWTProvisionToken lets a node join the cluster by presenting a signed
+// JWT rather than a long-lived shared secret, so CI runners and other
+// short-lived, cloud or Kubernetes-hosted nodes don't need a static
+// token baked in. The JWT is validated against the configured issuer's
+// JWKS endpoint and must satisfy BoundClaims before host certs for Roles
+// are issued.
+type JWTProvisionToken struct {
+	// Roles is the set of roles a node presenting a valid JWT may request.
+	Roles teleport.Roles `json:"roles"`
+	// Issuer is the expected `iss` claim, used to locate the JWKS endpoint.
+	Issuer string `json:"issuer"`
+	// Audience is the expected `aud` claim; a presented JWT minted for a
+	// different audience (e.g. another Teleport cluster trusting the same
+	// issuer) is rejected even if its signature and bound claims match.
+	Audience string `json:"aud"`
+	// JWKSURL is the endpoint the auth server fetches signing keys from.
+	// When empty, it's derived from Issuer using the OIDC discovery
+	// document convention (`<issuer>/.well-known/jwks.json`).
+	JWKSURL string `json:"jwks_url,omitempty"`
+	// BoundClaims restricts which presented JWTs are accepted: every
+	// key/value pair here must equal (as a string) the same-named claim
+	// in the token for it to be honored.
+	BoundClaims map[string]string `json:"bound_claims,omitempty"`
+}
+
+// CheckAndSetDefaults validates the token and fills in JWKSURL from
+// Issuer when it was left blank.
+func (t *JWTProvisionToken) CheckAndSetDefaults() error {
+	if t.Issuer == "" {
+		return trace.BadParameter("jwt provision token: issuer is required")
+	}
+	if t.Audience == "" {
+		return trace.BadParameter("jwt provision token: aud is required")
+	}
+	if len(t.Roles) == 0 {
+		return trace.BadParameter("jwt provision token: at least one role is required")
+	}
+	if t.JWKSURL == "" {
+		t.JWKSURL = t.Issuer + "/.well-known/jwks.json"
+	}
+	return nil
+}
+
+// MatchesClaims reports whether presentedClaims satisfies every entry in
+// BoundClaims. An empty BoundClaims set always matches.
+func (t *JWTProvisionToken) MatchesClaims(presentedClaims map[string]interface{}) bool {
+	for key, want := range t.BoundClaims {
+		got, ok := presentedClaims[key]
+		if !ok {
+			return false
+		}
+		gotStr, ok := got.(string)
+		if !ok || gotStr != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// LockoutPolicy generalizes the old fixed MaxLoginAttempts/AccountLockInterval
+// pair into an exponentially growing lockout: once MaxAttempts failures
+// have been recorded, each further failed attempt starts a new lockout
+// whose window is the previous one times Multiplier, up to MaxInterval,
+// and DecayOnSuccess resets the counter after a successful login so a
+// single mistyped password years ago doesn't linger.
+type LockoutPolicy struct {
+	// BaseInterval is the lockout duration after the first lockout-
+	// triggering failure.
+	BaseInterval time.Duration `json:"base_interval"`
+	// Multiplier scales BaseInterval on each subsequent lock. 2 doubles
+	// it every time, matching the 30s/1m/2m/... progression.
+	Multiplier float64 `json:"multiplier"`
+	// MaxInterval caps how long a lockout can grow to.
+	MaxInterval time.Duration `json:"max_interval"`
+	// MaxAttempts is how many consecutive failures are allowed before
+	// the first lockout is triggered.
+	MaxAttempts int `json:"max_attempts"`
+	// DecayOnSuccess resets the failure counter for a (user, source) pair
+	// after a successful login from that source.
+	DecayOnSuccess bool `json:"decay_on_success"`
+	// PerSourceIP tracks attempts keyed by (user, source IP) instead of
+	// just by user, so a single compromised source can be locked out
+	// without penalizing the user's logins from elsewhere.
+	PerSourceIP bool `json:"per_source_ip"`
+}
+
+// CheckAndSetDefaults fills in defaults for unset fields: a first lockout
+// of 30s after 3 failures, doubling on each further lockout, capped at 1h.
+func (p *LockoutPolicy) CheckAndSetDefaults() error {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseInterval == 0 {
+		p.BaseInterval = 30 * time.Second
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = 2
+	}
+	if p.Multiplier < 1 {
+		return trace.BadParameter("lockout policy: multiplier must be >= 1")
+	}
+	if p.MaxInterval == 0 {
+		p.MaxInterval = 1 * time.Hour
+	}
+	if p.MaxInterval < p.BaseInterval {
+		return trace.BadParameter("lockout policy: max_interval must be >= base_interval")
+	}
+	return nil
+}
+
+// LockoutFor returns how long an account should stay locked after
+// lockCount consecutive lockout-triggering failure cycles (1 being the
+// first lockout), growing BaseInterval by Multiplier each time and
+// capping at MaxInterval.
+func (p LockoutPolicy) LockoutFor(lockCount int) time.Duration {
+	if lockCount < 1 {
+		return 0
+	}
+	interval := float64(p.BaseInterval)
+	for i := 1; i < lockCount; i++ {
+		interval *= p.Multiplier
+		if time.Duration(interval) >= p.MaxInterval {
+			return p.MaxInterval
+		}
+	}
+	d := time.Duration(interval)
+	if d > p.MaxInterval {
+		return p.MaxInterval
+	}
+	return d
+}
+
+// LoginAttempt records one failed or successful login, keyed by user (and
+// optionally source IP), with a TTL so the backend can expire it without
+// an explicit cleanup pass.
+type LoginAttempt struct {
+	// Time is when the attempt happened.
+	Time time.Time `json:"time"`
+	// Success is whether the attempt succeeded.
+	Success bool `json:"success"`
+}
+
+// LockoutKey returns the backend key attempts should be recorded under
+// for a given user/sourceIP pair, honoring PerSourceIP.
+func (p LockoutPolicy) LockoutKey(user, sourceIP string) string {
+	if p.PerSourceIP && sourceIP != "" {
+		return user + "|" + sourceIP
+	}
+	return user
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"github.com/gravitational/teleport/lib/services/providers"
+	"github.com/gravitational/trace"
+)
+
+// GetProvider returns the configured provider profile for this
+// connector, or nil when Provider is empty (meaning: use the generic,
+// standards-compliant claim path). accessToken is the OAuth2 access
+// token issued alongside the ID token; it is stored in the provider's
+// Config.AccessToken, while the follow-up calls themselves use the token
+// passed to FetchClaims.
+func (o *OIDCConnectorV2) GetProvider(accessToken string) (providers.Provider, error) {
+	provider, err := providers.ForName(providers.Name(o.Spec.Provider), providers.Config{
+		ClientID:             o.Spec.ClientID,
+		UserInfoURL:          o.Spec.ProviderUserInfoURL,
+		RequiredHostedDomain: o.Spec.GoogleHostedDomain,
+		AccessToken:          accessToken,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return provider, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gravitational/trace"
+
+	"github.com/coreos/go-oidc/jose"
+)
+
+const bitbucketUserURL = "https://api.bitbucket.org/2.0/user"
+
+// bitbucketProvider fetches the caller's profile from Bitbucket's
+// non-standard userinfo endpoint, since Bitbucket's OAuth tokens don't
+// carry an OIDC-shaped ID token at all.
+type bitbucketProvider struct {
+	cfg Config
+}
+
+func (p *bitbucketProvider) FetchClaims(ctx context.Context, token string) (jose.Claims, error) {
+	url := bitbucketUserURL
+	if p.cfg.UserInfoURL != "" {
+		url = p.cfg.UserInfoURL
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, trace.AccessDenied("bitbucket: unexpected status %v fetching user profile", resp.StatusCode)
+	}
+
+	var profile struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	claims := make(jose.Claims)
+	claims["nickname"] = profile.Username
+	claims["email"] = profile.Email
+	return claims, nil
+}
+
+func (p *bitbucketProvider) ValidateHostedDomain(claims jose.Claims) error {
+	return nil
+}
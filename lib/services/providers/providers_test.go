@@ -0,0 +1,73 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"testing"
+
+	"github.com/coreos/go-oidc/jose"
+	. "gopkg.in/check.v1"
+)
+
+func TestProviders(t *testing.T) { TestingT(t) }
+
+type ProvidersSuite struct{}
+
+var _ = Suite(&ProvidersSuite{})
+
+func (s *ProvidersSuite) TestKeycloakFlattensResourceAccessRoles(c *C) {
+	p := &keycloakProvider{cfg: Config{ClientID: "teleport"}}
+
+	claims := jose.Claims{
+		"resource_access": map[string]interface{}{
+			"teleport": map[string]interface{}{
+				"roles": []interface{}{"admin", "auditor"},
+			},
+		},
+	}
+
+	out, err := keycloakFlatten(p, claims)
+	c.Assert(err, IsNil)
+	roles, ok := out["roles"].([]interface{})
+	c.Assert(ok, Equals, true)
+	c.Assert(roles, HasLen, 2)
+}
+
+func (s *ProvidersSuite) TestGoogleValidateHostedDomain(c *C) {
+	p := &googleProvider{cfg: Config{RequiredHostedDomain: "example.com"}}
+
+	good := jose.Claims{"hd": "example.com"}
+	c.Assert(p.ValidateHostedDomain(good), IsNil)
+
+	bad := jose.Claims{"hd": "other.com"}
+	c.Assert(p.ValidateHostedDomain(bad), NotNil)
+}
+
+func (s *ProvidersSuite) TestForName(c *C) {
+	provider, err := ForName(Generic, Config{})
+	c.Assert(err, IsNil)
+	c.Assert(provider, IsNil)
+
+	provider, err = ForName(Keycloak, Config{})
+	c.Assert(err, IsNil)
+	c.Assert(provider, NotNil)
+
+	// a typo'd provider name must fail loudly rather than silently
+	// falling back to the generic path and leaving the profile disabled.
+	_, err = ForName(Name("keyclaok"), Config{})
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// templateFuncs is the curated helper library every RoleTemplate field is
+// rendered with, beyond Go templates' built-in `index`. It's kept small
+// and deliberate rather than exposing the entire text/template
+// sprig-style grab bag, since these functions run against untrusted IdP
+// claims.
+var templateFuncs = template.FuncMap{
+	"split":          strings.Split,
+	"join":           func(sep string, parts []string) string { return strings.Join(parts, sep) },
+	"toLower":        strings.ToLower,
+	"hasPrefix":      strings.HasPrefix,
+	"default":        templateDefault,
+	"regexReplaceAll": templateRegexReplaceAll,
+	"email_local":    templateEmailLocal,
+	"email_domain":   templateEmailDomain,
+	"claim":          templateClaim,
+}
+
+// templateDefault returns value unless it's absent or empty, in which case
+// it returns fallback - handy for optional claims like `{{default "user" (index . "nickname")}}`.
+// value is an interface{} so that `index` on a missing claim (a nil value)
+// reaches this function instead of failing argument type checking.
+func templateDefault(fallback string, value interface{}) string {
+	if value == nil {
+		return fallback
+	}
+	s := fmt.Sprint(value)
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// templateRegexReplaceAll is regexp.ReplaceAllString with the pattern
+// first so it composes naturally with a pipeline:
+// `{{regexReplaceAll "@.*$" "" (index . "email")}}`.
+func templateRegexReplaceAll(pattern, replacement, input string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	return re.ReplaceAllString(input, replacement), nil
+}
+
+// templateEmailLocal and templateEmailDomain split an email claim into
+// its local-part and domain, the two halves role templates most often
+// want independently (e.g. mapping the domain to a namespace).
+func templateEmailLocal(email string) string {
+	local, _, _ := strings.Cut(email, "@")
+	return local
+}
+
+func templateEmailDomain(email string) string {
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return ""
+	}
+	return domain
+}
+
+// templateClaim walks a dotted path through nested claim objects, the
+// shape IdPs like Keycloak emit group/role claims in (e.g.
+// `resource_access.myclient.roles`), returning an error if any segment
+// along the path is missing so templates fail closed instead of silently
+// rendering `<no value>`.
+func templateClaim(path string, claims map[string]interface{}) (interface{}, error) {
+	segments := strings.Split(path, ".")
+	var current interface{} = claims
+	for i, seg := range segments {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, trace.NotFound("claim path %q: %q is not an object at segment %d", path, seg, i)
+		}
+		value, ok := m[seg]
+		if !ok {
+			return nil, trace.NotFound("claim path %q: missing claim %q", path, seg)
+		}
+		current = value
+	}
+	return current, nil
+}
+
+// renderTemplateString executes templateText (a Go template referencing
+// claims via `.`, e.g. `{{index . "email"}}` or `{{claim "a.b.c" .}}`)
+// against claims and fails closed - a missing claim is a hard error
+// rather than the default `<no value>` text/template would otherwise
+// produce.
+func renderTemplateString(name, templateText string, claims map[string]interface{}) (string, error) {
+	if templateText == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(templateFuncs).
+		Parse(templateText)
+	if err != nil {
+		return "", trace.Wrap(err, "parsing role template %q", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, claims); err != nil {
+		return "", trace.Wrap(err, "rendering role template %q", name)
+	}
+
+	rendered := buf.String()
+	if strings.Contains(rendered, "<no value>") {
+		return "", trace.BadParameter("role template %q referenced a claim that was not present", name)
+	}
+	return rendered, nil
+}
+
+// renderTemplateStrings renders each entry of in with renderTemplateString.
+func renderTemplateStrings(name string, in []string, claims map[string]interface{}) ([]string, error) {
+	out := make([]string, len(in))
+	for i, s := range in {
+		rendered, err := renderTemplateString(name, s, claims)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		out[i] = rendered
+	}
+	return out, nil
+}
+
+// renderTemplateStringMap renders every key and value of in, used for
+// NodeLabels where both the selector key and value can reference claims.
+func renderTemplateStringMap(name string, in map[string]string, claims map[string]interface{}) (map[string]string, error) {
+	if in == nil {
+		return nil, nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		renderedKey, err := renderTemplateString(name, k, claims)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		renderedValue, err := renderTemplateString(name, v, claims)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		out[renderedKey] = renderedValue
+	}
+	return out, nil
+}
+
+// renderTemplateStringSliceMap renders every key and every value string of
+// in, used for Resources: a resource kind (possibly itself templated, e.g.
+// from a Keycloak `resource_access` claim) mapped to the verbs allowed
+// against it.
+func renderTemplateStringSliceMap(name string, in map[string][]string, claims map[string]interface{}) (map[string][]string, error) {
+	if in == nil {
+		return nil, nil
+	}
+	out := make(map[string][]string, len(in))
+	for k, values := range in {
+		renderedKey, err := renderTemplateString(name, k, claims)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		renderedValues, err := renderTemplateStrings(name, values, claims)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		out[renderedKey] = renderedValues
+	}
+	return out, nil
+}
+
+// renderTemplateDuration renders templateText (e.g. `{{claim "ttl" .}}h`)
+// against claims and parses the result with time.ParseDuration. An empty
+// templateText is not an error - it means the field wasn't templated, so
+// fallback is returned unchanged, matching renderTemplateString's
+// no-template-means-no-op behavior.
+func renderTemplateDuration(name, templateText string, fallback Duration, claims map[string]interface{}) (Duration, error) {
+	if templateText == "" {
+		return fallback, nil
+	}
+
+	rendered, err := renderTemplateString(name, templateText, claims)
+	if err != nil {
+		return fallback, trace.Wrap(err)
+	}
+
+	parsed, err := time.ParseDuration(rendered)
+	if err != nil {
+		return fallback, trace.BadParameter("role template %q rendered %q, which is not a valid duration: %v", name, rendered, err)
+	}
+	return NewDuration(parsed), nil
+}
+
+// renderRoleTemplate renders every templated string field of tmpl (Name,
+// Logins, NodeLabels, Namespaces, Resources) against claims and builds
+// the resulting Role, replacing the old index-only rendering that left
+// NodeLabels as a TODO. MaxSessionTTL itself is a Duration and can't
+// reference a claim directly, so MaxSessionTTLTemplate - a duration
+// expression like `{{claim "ttl_hours" .}}h` - is rendered and parsed
+// instead when set; MaxSessionTTL is used as-is otherwise.
+func renderRoleTemplate(tmpl *RoleTemplate, claims map[string]interface{}) (Role, error) {
+	name, err := renderTemplateString("name", tmpl.Name, claims)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	logins, err := renderTemplateStrings("logins", tmpl.Logins, claims)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	maxSessionTTL, err := renderTemplateDuration("max_session_ttl", tmpl.MaxSessionTTLTemplate, tmpl.MaxSessionTTL, claims)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	nodeLabels, err := renderTemplateStringMap("node_labels", tmpl.NodeLabels, claims)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	namespaces, err := renderTemplateStrings("namespaces", tmpl.Namespaces, claims)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	resources, err := renderTemplateStringSliceMap("resources", tmpl.Resources, claims)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return NewRole(name, RoleSpecV2{
+		Logins:        logins,
+		MaxSessionTTL: maxSessionTTL,
+		NodeLabels:    nodeLabels,
+		Namespaces:    namespaces,
+		Resources:     resources,
+		ForwardAgent:  tmpl.ForwardAgent,
+	})
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2017 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"github.com/gravitational/teleport/lib/defaults"
+
+	"gopkg.in/check.v1"
+)
+
+type SAMLSuite struct{}
+
+var _ = check.Suite(&SAMLSuite{})
+
+func (s *SAMLSuite) TestCheckAndSetDefaults(c *check.C) {
+	connector := NewSAMLConnector("okta", SAMLConnectorSpecV2{
+		EntityDescriptor:         "<EntityDescriptor/>",
+		SSOURL:                   "https://okta.example.com/sso/saml",
+		AssertionConsumerService: "https://localhost:3080/v1/webapi/saml/acs",
+	})
+	c.Assert(connector.CheckAndSetDefaults(), check.IsNil)
+	c.Assert(connector.Metadata.Namespace, check.Equals, defaults.Namespace)
+
+	bad := NewSAMLConnector("okta", SAMLConnectorSpecV2{SSOURL: "https://okta.example.com/sso/saml"})
+	c.Assert(bad.CheckAndSetDefaults(), check.NotNil)
+}
+
+func (s *SAMLSuite) TestRoleFromTemplate(c *check.C) {
+	connector := NewSAMLConnector("okta", SAMLConnectorSpecV2{
+		EntityDescriptor:         "<EntityDescriptor/>",
+		SSOURL:                   "https://okta.example.com/sso/saml",
+		AssertionConsumerService: "https://localhost:3080/v1/webapi/saml/acs",
+		AttributesToRoles: []AttributeMapping{
+			{
+				Attribute: "groups",
+				Value:     "teleport-user",
+				RoleTemplate: &RoleTemplate{
+					Name:   `{{index . "email"}}`,
+					Logins: []string{`{{index . "email"}}`},
+				},
+			},
+		},
+	})
+
+	attributes := map[string][]string{
+		"groups": {"teleport-user"},
+		"email":  {"foo@example.com"},
+	}
+
+	role, err := connector.RoleFromTemplate(attributes)
+	c.Assert(err, check.IsNil)
+	c.Assert(role.GetName(), check.Equals, "foo@example.com")
+}